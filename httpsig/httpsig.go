@@ -0,0 +1,324 @@
+// Package httpsig builds and parses the Signature, Signature-Input,
+// Accept-Signature and Signature-Agent headers defined by RFC 9421, HTTP
+// Message Signatures, using the sf package's Dict, InnerList and Item
+// primitives as its wire representation.
+package httpsig
+
+import (
+	"fmt"
+	"strings"
+
+	sf "github.com/httpsig/go-sf"
+)
+
+// Parameter keys recognized on a covered component identifier, as defined
+// in RFC 9421, Section 2.1.
+const (
+	ParamSF  = "sf"  // serialize the field as a structured field before signing
+	ParamBS  = "bs"  // byte-sequence-encode each field value
+	ParamReq = "req" // for responses, cover the associated request's component
+	ParamTr  = "tr"  // for trailers, cover the trailer instead of the header
+	ParamKey = "key" // select one member of a Dict-valued field
+)
+
+// Parameter keys recognized on a Signature-Input label's own parameters, as
+// defined in RFC 9421, Section 2.3.
+const (
+	ParamCreated = "created"
+	ParamExpires = "expires"
+	ParamNonce   = "nonce"
+	ParamAlg     = "alg"
+	ParamKeyID   = "keyid"
+	ParamTag     = "tag"
+)
+
+// Component is one covered component identifier: either an HTTP field name
+// or a derived component name such as "@method", together with any of the
+// parameters above that modify how it is canonicalized.
+type Component struct {
+	Name   string
+	Params sf.ParamList
+}
+
+// Encode serializes the component as the sf String item that represents it
+// in a Signature-Input's inner list.
+func (c Component) Encode() string {
+	return (&sf.Item{Bare: sf.String(c.Name), Params: c.Params}).Encode()
+}
+
+// SignatureParams is the parameter list attached to a Signature-Input
+// label: created, expires, nonce, alg, keyid and tag, as defined in RFC
+// 9421, Section 2.3.
+type SignatureParams = sf.ParamList
+
+// CoveredComponents is the ordered list of components covered by a
+// signature, together with the signature's own SignatureParams (created,
+// keyid, alg, ...). It mirrors one label's value in a Signature-Input
+// header.
+type CoveredComponents struct {
+	Components []Component
+	Params     SignatureParams
+}
+
+// Add appends a covered component and returns the modified list.
+func (cc CoveredComponents) Add(name string, params sf.ParamList) CoveredComponents {
+	cc.Components = append(cc.Components, Component{Name: name, Params: params})
+	return cc
+}
+
+// innerList returns cc as the sf.InnerList used to encode it.
+func (cc CoveredComponents) innerList() *sf.InnerList {
+	items := make([]sf.Item, len(cc.Components))
+	for i, c := range cc.Components {
+		items[i] = sf.Item{Bare: sf.String(c.Name), Params: c.Params}
+	}
+	return &sf.InnerList{Items: items, Params: cc.Params}
+}
+
+// Encode serializes cc as it appears as a Signature-Input label's value.
+func (cc CoveredComponents) Encode() string {
+	return cc.innerList().Encode()
+}
+
+// ParseSignatureInput parses a Signature-Input header into a map of label
+// to CoveredComponents, ready for a verifier to recompute each label's
+// signature base.
+func ParseSignatureInput(header []string) (map[string]CoveredComponents, error) {
+	return parseCoveredComponentsDict("Signature-Input", header)
+}
+
+// ParseAcceptSignature parses an Accept-Signature header into a map of
+// label to CoveredComponents, requested by a server for a client to sign in
+// a subsequent request. It uses the same Dict-of-inner-list wire format as
+// Signature-Input.
+func ParseAcceptSignature(header []string) (map[string]CoveredComponents, error) {
+	return parseCoveredComponentsDict("Accept-Signature", header)
+}
+
+func parseCoveredComponentsDict(field string, header []string) (map[string]CoveredComponents, error) {
+	d, err := sf.ParseDict(header)
+	if err != nil {
+		return nil, fmt.Errorf("httpsig: %s: %w", field, err)
+	}
+	out := make(map[string]CoveredComponents, len(d))
+	for _, p := range d {
+		il, ok := p.Value.(*sf.InnerList)
+		if !ok {
+			return nil, fmt.Errorf("httpsig: %s: label %q: value is not an inner list", field, p.Key)
+		}
+		cc := CoveredComponents{Params: il.Params}
+		for _, it := range il.Items {
+			name, ok := it.Bare.(sf.String)
+			if !ok {
+				return nil, fmt.Errorf("httpsig: %s: label %q: component identifier is not a string", field, p.Key)
+			}
+			cc.Components = append(cc.Components, Component{Name: string(name), Params: it.Params})
+		}
+		out[p.Key] = cc
+	}
+	return out, nil
+}
+
+// ParseSignature parses a Signature header into a map of label to raw
+// signature bytes, ready for a verifier to check against the corresponding
+// label's signature base.
+func ParseSignature(header []string) (map[string][]byte, error) {
+	d, err := sf.ParseDict(header)
+	if err != nil {
+		return nil, fmt.Errorf("httpsig: Signature: %w", err)
+	}
+	out := make(map[string][]byte, len(d))
+	for _, p := range d {
+		it, ok := p.Value.(*sf.Item)
+		if !ok {
+			return nil, fmt.Errorf("httpsig: Signature: label %q: value is not an item", p.Key)
+		}
+		bs, ok := it.Bare.(sf.ByteSeq)
+		if !ok {
+			return nil, fmt.Errorf("httpsig: Signature: label %q: value is not a byte sequence", p.Key)
+		}
+		out[p.Key] = []byte(bs)
+	}
+	return out, nil
+}
+
+// EncodeSignature serializes sigs, a map of label to raw signature bytes,
+// as a Signature header value.
+func EncodeSignature(sigs map[string][]byte) string {
+	var d sf.Dict
+	for label, sig := range sigs {
+		d = d.Add(label, &sf.Item{Bare: sf.ByteSeq(sig)})
+	}
+	return d.Encode()
+}
+
+// ParseSignatureAgent parses a Signature-Agent header's single String item,
+// identifying the component that produced the signature, typically a URI.
+func ParseSignatureAgent(header []string) (string, error) {
+	if len(header) != 1 {
+		return "", fmt.Errorf("httpsig: Signature-Agent: expected exactly one header line, got %d", len(header))
+	}
+	it, err := sf.ParseItemLine(strings.TrimSpace(header[0]))
+	if err != nil {
+		return "", fmt.Errorf("httpsig: Signature-Agent: %w", err)
+	}
+	s, ok := it.Bare.(sf.String)
+	if !ok {
+		return "", fmt.Errorf("httpsig: Signature-Agent: value is not a string")
+	}
+	return string(s), nil
+}
+
+// EncodeSignatureAgent serializes agent as a Signature-Agent header value.
+func EncodeSignatureAgent(agent string) string {
+	return (&sf.Item{Bare: sf.String(agent)}).Encode()
+}
+
+// Message is the minimal view of an HTTP request or response a verifier or
+// signer needs in order to canonicalize covered components: the derived
+// components and the field values to draw from.
+type Message struct {
+	Method    string
+	TargetURI string
+	Authority string
+	Scheme    string
+	Path      string
+	Query     string
+	Status    int // non-zero for a response message
+	Header    map[string][]string
+	Trailer   map[string][]string
+
+	// Request is the request associated with this message, used when a
+	// covered component carries the req parameter on a response message.
+	Request *Message
+
+	// DictFields lists, by lowercase field name, the fields that are known
+	// to be Dictionary Structured Fields rather than List Structured
+	// Fields. RFC 9421's sf parameter only says a field must be serialized
+	// as a structured field before signing; the wire value alone cannot
+	// distinguish a Dictionary from a List (e.g. "foo, bar" parses as
+	// either), so the caller must declare it here.
+	DictFields map[string]bool
+}
+
+func (m Message) fieldValues(name string, trailer bool) ([]string, bool) {
+	header := m.Header
+	if trailer {
+		header = m.Trailer
+	}
+	for k, v := range header {
+		if strings.EqualFold(k, name) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// derived returns the value of a derived component, i.e. one whose name
+// starts with "@".
+func (m Message) derived(name string) (string, error) {
+	switch name {
+	case "@method":
+		return m.Method, nil
+	case "@target-uri":
+		return m.TargetURI, nil
+	case "@authority":
+		return strings.ToLower(m.Authority), nil
+	case "@scheme":
+		return strings.ToLower(m.Scheme), nil
+	case "@request-target":
+		if m.Query == "" {
+			return m.Path, nil
+		}
+		return m.Path + "?" + m.Query, nil
+	case "@path":
+		return m.Path, nil
+	case "@query":
+		if m.Query == "" {
+			return "?", nil
+		}
+		return "?" + m.Query, nil
+	case "@status":
+		return fmt.Sprintf("%d", m.Status), nil
+	}
+	return "", fmt.Errorf("httpsig: unknown derived component %q", name)
+}
+
+// canonicalize returns the signature base line value for one covered
+// component, per RFC 9421, Section 2.5.
+func (m Message) canonicalize(c Component) (string, error) {
+	msg := m
+	if c.Params.Get(ParamReq) != nil {
+		if m.Request == nil {
+			return "", fmt.Errorf("httpsig: component %q: req parameter set but message has no associated request", c.Name)
+		}
+		msg = *m.Request
+	}
+	if strings.HasPrefix(c.Name, "@") {
+		return msg.derived(c.Name)
+	}
+	vals, ok := msg.fieldValues(c.Name, c.Params.Get(ParamTr) != nil)
+	if !ok {
+		return "", fmt.Errorf("httpsig: component %q not present on message", c.Name)
+	}
+	switch {
+	case c.Params.Get(ParamBS) != nil:
+		items := make(sf.List, len(vals))
+		for i, v := range vals {
+			items[i] = &sf.Item{Bare: sf.ByteSeq(strings.TrimSpace(v))}
+		}
+		return items.Encode(), nil
+	case c.Params.Get(ParamSF) != nil:
+		if msg.DictFields[strings.ToLower(c.Name)] {
+			d, err := sf.ParseDict(vals)
+			if err != nil {
+				return "", fmt.Errorf("httpsig: component %q: not a dictionary: %w", c.Name, err)
+			}
+			return d.Encode(), nil
+		}
+		l, err := sf.ParseList(vals)
+		if err != nil {
+			return "", fmt.Errorf("httpsig: component %q: not a list: %w", c.Name, err)
+		}
+		return l.Encode(), nil
+	case c.Params.Get(ParamKey) != nil:
+		key, ok := c.Params.Get(ParamKey).(sf.String)
+		if !ok {
+			return "", fmt.Errorf("httpsig: component %q: key parameter is not a string", c.Name)
+		}
+		d, err := sf.ParseDict(vals)
+		if err != nil {
+			return "", fmt.Errorf("httpsig: component %q: not a dictionary: %w", c.Name, err)
+		}
+		member := d.Get(string(key))
+		if member == nil {
+			return "", fmt.Errorf("httpsig: component %q: key %q not present", c.Name, key)
+		}
+		return member.Encode(), nil
+	default:
+		trimmed := make([]string, len(vals))
+		for i, v := range vals {
+			trimmed[i] = strings.TrimSpace(v)
+		}
+		return strings.Join(trimmed, ", "), nil
+	}
+}
+
+// SignatureBase computes the signature base string for the components and
+// signature parameters in cc, per RFC 9421, Section 2.5.
+func SignatureBase(m Message, cc CoveredComponents) (string, error) {
+	var sb strings.Builder
+	for _, c := range cc.Components {
+		val, err := m.canonicalize(c)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(c.Encode())
+		sb.WriteString(": ")
+		sb.WriteString(val)
+		sb.WriteString("\n")
+	}
+	sb.WriteString(`"@signature-params": `)
+	sb.WriteString(cc.innerList().Encode())
+	return sb.String(), nil
+}
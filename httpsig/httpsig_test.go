@@ -0,0 +1,189 @@
+package httpsig
+
+import (
+	"fmt"
+
+	sf "github.com/httpsig/go-sf"
+)
+
+func ExampleParseSignatureInput() {
+	m, err := ParseSignatureInput([]string{
+		`sig1=("@method" "@authority" "@path");created=1618884473;keyid="test-key-ed25519"`,
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	cc := m["sig1"]
+	for _, c := range cc.Components {
+		fmt.Println(c.Name)
+	}
+	fmt.Println(cc.Params.Get(ParamKeyID))
+
+	// Output:
+	// @method
+	// @authority
+	// @path
+	// test-key-ed25519
+}
+
+func ExampleCoveredComponents_Encode() {
+	cc := CoveredComponents{}.
+		Add("@method", nil).
+		Add("@authority", nil).
+		Add("@path", nil)
+	cc.Params = cc.Params.Add(ParamCreated, sf.Integer(1618884473))
+	cc.Params = cc.Params.Add(ParamKeyID, sf.String("test-key-ed25519"))
+	fmt.Println(cc.Encode())
+
+	// Output:
+	// ("@method" "@authority" "@path");created=1618884473;keyid="test-key-ed25519"
+}
+
+func ExampleSignatureBase() {
+	m := Message{
+		Method:    "POST",
+		Authority: "example.com",
+		Path:      "/foo",
+		Header: map[string][]string{
+			"Content-Type": {"application/json"},
+		},
+	}
+	cc := CoveredComponents{}.
+		Add("@method", nil).
+		Add("@authority", nil).
+		Add("@path", nil).
+		Add("content-type", nil)
+	cc.Params = cc.Params.Add(ParamCreated, sf.Integer(1618884473))
+	cc.Params = cc.Params.Add(ParamKeyID, sf.String("test-key-ed25519"))
+
+	base, err := SignatureBase(m, cc)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Print(base)
+
+	// Output:
+	// "@method": POST
+	// "@authority": example.com
+	// "@path": /foo
+	// "content-type": application/json
+	// "@signature-params": ("@method" "@authority" "@path" "content-type");created=1618884473;keyid="test-key-ed25519"
+}
+
+func ExampleSignatureBase_requestTarget() {
+	m := Message{Method: "GET", Path: "/foo", Query: "q=1"}
+	cc := CoveredComponents{}.Add("@request-target", nil)
+
+	base, err := SignatureBase(m, cc)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Print(base)
+
+	// Output:
+	// "@request-target": /foo?q=1
+	// "@signature-params": ("@request-target")
+}
+
+func ExampleSignatureBase_reqAndTrailer() {
+	req := Message{Method: "POST", Authority: "example.com"}
+	resp := Message{
+		Status:  200,
+		Request: &req,
+		Trailer: map[string][]string{"Expires": {"Wed, 09 Aug 2023 23:34:45 GMT"}},
+	}
+	cc := CoveredComponents{}.
+		Add("@method", sf.ParamList{}.Add(ParamReq, sf.Bool(true))).
+		Add("expires", sf.ParamList{}.Add(ParamTr, sf.Bool(true)))
+
+	base, err := SignatureBase(resp, cc)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Print(base)
+
+	// Output:
+	// "@method";req: POST
+	// "expires";tr: Wed, 09 Aug 2023 23:34:45 GMT
+	// "@signature-params": ("@method";req "expires";tr)
+}
+
+func ExampleSignatureBase_sfDictField() {
+	m := Message{
+		Header:     map[string][]string{"Example-Dict": {"a=1, b=2"}},
+		DictFields: map[string]bool{"example-dict": true},
+	}
+	cc := CoveredComponents{}.Add("example-dict", sf.ParamList{}.Add(ParamSF, sf.Bool(true)))
+
+	base, err := SignatureBase(m, cc)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Print(base)
+
+	// Output:
+	// "example-dict";sf: a=1, b=2
+	// "@signature-params": ("example-dict";sf)
+}
+
+func ExampleSignatureBase_bsField() {
+	m := Message{
+		Header: map[string][]string{"Example-Header": {"  hello  "}},
+	}
+	cc := CoveredComponents{}.Add("example-header", sf.ParamList{}.Add(ParamBS, sf.Bool(true)))
+
+	base, err := SignatureBase(m, cc)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Print(base)
+
+	// Output:
+	// "example-header";bs: :aGVsbG8=:
+	// "@signature-params": ("example-header";bs)
+}
+
+func ExampleParseSignature() {
+	sigs, err := ParseSignature([]string{`sig1=:aGVsbG8=:`})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("%s\n", sigs["sig1"])
+
+	// Output:
+	// hello
+}
+
+func ExampleParseAcceptSignature() {
+	m, err := ParseAcceptSignature([]string{`sig1=("@method" "@authority")`})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, c := range m["sig1"].Components {
+		fmt.Println(c.Name)
+	}
+
+	// Output:
+	// @method
+	// @authority
+}
+
+func ExampleParseSignatureAgent() {
+	agent, err := ParseSignatureAgent([]string{`"https://signer.example"`})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(agent)
+
+	// Output:
+	// https://signer.example
+}
@@ -0,0 +1,456 @@
+package sf
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Params is the parameter list attached to a dictionary member or item when
+// marshaling or unmarshaling through struct tags. It is an alias for
+// ParamList so a struct field may be declared as `sf.Params` instead.
+type Params = ParamList
+
+// Marshal returns the structured-field encoding of v.
+//
+// v must be a struct (encoded as a Dict), a slice (encoded as a List), or a
+// value that maps directly to a BareItem (encoded as an Item). See
+// MarshalDict, MarshalList and MarshalItem for the struct tag rules applied
+// to each shape.
+func Marshal(v any) (string, error) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	switch {
+	case rv.Kind() == reflect.Struct && rv.Type() != timeType:
+		d, err := marshalDict(rv)
+		if err != nil {
+			return "", err
+		}
+		return d.Encode(), nil
+	case rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() != reflect.Uint8:
+		l, err := marshalList(rv)
+		if err != nil {
+			return "", err
+		}
+		return l.Encode(), nil
+	default:
+		it, err := marshalItem(rv)
+		if err != nil {
+			return "", err
+		}
+		return it.Encode(), nil
+	}
+}
+
+// MarshalDict marshals v, which must be a struct, into a Dict.
+//
+// Exported fields are encoded in declaration order using `sf:"name,kind"`
+// tags, where kind is "item" (the default for non-slice and []byte fields)
+// or "inner" (the default for other slice fields). A field tagged
+// `sf:"name,params"` must share its name with a field declared before it
+// and supplies that member's Params; its type must be Params (ParamList) or
+// a struct of its own `sf`-tagged fields. A field tagged `sf:"-"` is always
+// skipped.
+func MarshalDict(v any) (Dict, error) {
+	return marshalDict(reflect.Indirect(reflect.ValueOf(v)))
+}
+
+// MarshalList marshals v, which must be a slice, into a List.
+func MarshalList(v any) (List, error) {
+	return marshalList(reflect.Indirect(reflect.ValueOf(v)))
+}
+
+// MarshalItem marshals v into an Item.
+func MarshalItem(v any) (*Item, error) {
+	return marshalItem(reflect.Indirect(reflect.ValueOf(v)))
+}
+
+// Unmarshal parses header, the multi-line form used by http.Header, and
+// stores the result in the value pointed to by v. v must be a pointer to a
+// struct (populated from a Dict), a slice (populated from a List), or a
+// value that maps directly to a BareItem (populated from an Item).
+func Unmarshal(header []string, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("sf: Unmarshal requires a non-nil pointer, got %T", v)
+	}
+	elem := rv.Elem()
+	switch {
+	case elem.Kind() == reflect.Struct && elem.Type() != timeType:
+		d, err := ParseDict(header)
+		if err != nil {
+			return err
+		}
+		return unmarshalDict(d, elem)
+	case elem.Kind() == reflect.Slice && elem.Type().Elem().Kind() != reflect.Uint8:
+		l, err := ParseList(header)
+		if err != nil {
+			return err
+		}
+		return unmarshalList(l, elem)
+	default:
+		it, err := ParseItemLine(joinMultiLines(header))
+		if err != nil {
+			return err
+		}
+		return unmarshalBareItem(it.Bare, elem)
+	}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+type sfTag struct {
+	name string
+	opts []string
+}
+
+func parseSFTag(f reflect.StructField) (sfTag, bool) {
+	tag, ok := f.Tag.Lookup("sf")
+	if tag == "-" {
+		return sfTag{}, false
+	}
+	if !ok {
+		tag = ""
+	}
+	parts := strings.Split(tag, ",")
+	name := parts[0]
+	if name == "" {
+		name = strings.ToLower(f.Name[:1]) + f.Name[1:]
+	}
+	return sfTag{name: name, opts: parts[1:]}, true
+}
+
+func (t sfTag) has(opt string) bool {
+	for _, o := range t.opts {
+		if o == opt {
+			return true
+		}
+	}
+	return false
+}
+
+// dictField groups the value and, if present, params field that together
+// describe one Dict member.
+type dictField struct {
+	name      string
+	value     reflect.Value
+	valueTag  sfTag
+	params    reflect.Value
+	hasParams bool
+}
+
+func collectDictFields(rv reflect.Value) ([]dictField, error) {
+	var fields []dictField
+	index := map[string]int{}
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+		tag, ok := parseSFTag(sf)
+		if !ok {
+			continue
+		}
+		if tag.has("params") {
+			idx, seen := index[tag.name]
+			if !seen {
+				return nil, fmt.Errorf("sf: field %q: params tag has no matching value field", sf.Name)
+			}
+			fields[idx].params = rv.Field(i)
+			fields[idx].hasParams = true
+			continue
+		}
+		if idx, seen := index[tag.name]; seen {
+			fields[idx].value = rv.Field(i)
+			fields[idx].valueTag = tag
+			continue
+		}
+		index[tag.name] = len(fields)
+		fields = append(fields, dictField{name: tag.name, value: rv.Field(i), valueTag: tag})
+	}
+	return fields, nil
+}
+
+func marshalDict(rv reflect.Value) (Dict, error) {
+	fields, err := collectDictFields(rv)
+	if err != nil {
+		return nil, err
+	}
+	var d Dict
+	for _, f := range fields {
+		member, err := marshalMember(f.value, f.valueTag)
+		if err != nil {
+			return nil, fmt.Errorf("sf: field %q: %w", f.name, err)
+		}
+		if f.hasParams {
+			params, err := paramsOf(f.params)
+			if err != nil {
+				return nil, fmt.Errorf("sf: field %q: %w", f.name, err)
+			}
+			setParams(member, params)
+		}
+		d = d.Add(f.name, member)
+	}
+	return d, nil
+}
+
+func paramsOf(rv reflect.Value) (ParamList, error) {
+	if rv.Type() == reflect.TypeOf(ParamList(nil)) {
+		return rv.Interface().(ParamList), nil
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("sf: params field must be Params or a struct, got %s", rv.Type())
+	}
+	fields, err := collectDictFields(rv)
+	if err != nil {
+		return nil, err
+	}
+	var params ParamList
+	for _, f := range fields {
+		b, err := toBareItem(f.value, f.valueTag)
+		if err != nil {
+			return nil, fmt.Errorf("param %q: %w", f.name, err)
+		}
+		params = params.Add(f.name, b)
+	}
+	return params, nil
+}
+
+func setParams(m Member, params ParamList) {
+	switch v := m.(type) {
+	case *Item:
+		v.Params = params
+	case *InnerList:
+		v.Params = params
+	}
+}
+
+func marshalMember(rv reflect.Value, tag sfTag) (Member, error) {
+	isSlice := rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() != reflect.Uint8
+	if isSlice && (!tag.has("item") || tag.has("inner")) {
+		items := make([]Item, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			it, err := marshalItem(rv.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, *it)
+		}
+		return &InnerList{Items: items}, nil
+	}
+	b, err := toBareItem(rv, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &Item{Bare: b}, nil
+}
+
+func marshalList(rv reflect.Value) (List, error) {
+	l := make(List, 0, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		elem := rv.Index(i)
+		if elem.Kind() == reflect.Slice && elem.Type().Elem().Kind() != reflect.Uint8 {
+			m, err := marshalMember(elem, sfTag{opts: []string{"inner"}})
+			if err != nil {
+				return nil, fmt.Errorf("sf: element %d: %w", i, err)
+			}
+			l = append(l, m)
+			continue
+		}
+		it, err := marshalItem(elem)
+		if err != nil {
+			return nil, fmt.Errorf("sf: element %d: %w", i, err)
+		}
+		l = append(l, it)
+	}
+	return l, nil
+}
+
+func marshalItem(rv reflect.Value) (*Item, error) {
+	b, err := toBareItem(rv, sfTag{})
+	if err != nil {
+		return nil, err
+	}
+	return &Item{Bare: b}, nil
+}
+
+func toBareItem(rv reflect.Value, tag sfTag) (BareItem, error) {
+	if rv.Type() == timeType {
+		return Date(rv.Interface().(time.Time)), nil
+	}
+	switch rv.Kind() {
+	case reflect.Bool:
+		return Bool(rv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if tag.has("date") {
+			return Date(time.Unix(rv.Int(), 0).UTC()), nil
+		}
+		return Integer(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Integer(int64(rv.Uint())), nil
+	case reflect.Float32, reflect.Float64:
+		return Decimal(int64(math.Round(rv.Float() * 1000))), nil
+	case reflect.String:
+		switch {
+		case tag.has("token"):
+			return Token(rv.String()), nil
+		case tag.has("display"):
+			return DispString(rv.String()), nil
+		default:
+			return String(rv.String()), nil
+		}
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return ByteSeq(rv.Bytes()), nil
+		}
+	}
+	return nil, fmt.Errorf("sf: unsupported field type %s", rv.Type())
+}
+
+func unmarshalDict(d Dict, rv reflect.Value) error {
+	fields, err := collectDictFields(rv)
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		member := d.Get(f.name)
+		if member == nil {
+			continue
+		}
+		if err := unmarshalMember(member, f.value, f.valueTag); err != nil {
+			return fmt.Errorf("sf: key %q: %w", f.name, err)
+		}
+		if f.hasParams {
+			if err := unmarshalParams(paramsOfMember(member), f.params); err != nil {
+				return fmt.Errorf("sf: key %q: %w", f.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func paramsOfMember(m Member) ParamList {
+	switch v := m.(type) {
+	case *Item:
+		return v.Params
+	case *InnerList:
+		return v.Params
+	}
+	return nil
+}
+
+func unmarshalParams(params ParamList, rv reflect.Value) error {
+	if rv.Type() == reflect.TypeOf(ParamList(nil)) {
+		rv.Set(reflect.ValueOf(params))
+		return nil
+	}
+	fields, err := collectDictFields(rv)
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		b := params.Get(f.name)
+		if b == nil {
+			continue
+		}
+		if err := unmarshalBareItem(b, f.value); err != nil {
+			return fmt.Errorf("param %q: %w", f.name, err)
+		}
+	}
+	return nil
+}
+
+func unmarshalMember(m Member, rv reflect.Value, tag sfTag) error {
+	if inner, ok := m.(*InnerList); ok {
+		if rv.Kind() != reflect.Slice {
+			return fmt.Errorf("sf: cannot unmarshal inner list into %s", rv.Type())
+		}
+		slice := reflect.MakeSlice(rv.Type(), len(inner.Items), len(inner.Items))
+		for i := range inner.Items {
+			if err := unmarshalBareItem(inner.Items[i].Bare, slice.Index(i)); err != nil {
+				return fmt.Errorf("item %d: %w", i, err)
+			}
+		}
+		rv.Set(slice)
+		return nil
+	}
+	it := m.(*Item)
+	return unmarshalBareItem(it.Bare, rv)
+}
+
+func unmarshalList(l List, rv reflect.Value) error {
+	slice := reflect.MakeSlice(rv.Type(), len(l), len(l))
+	for i, m := range l {
+		if err := unmarshalMember(m, slice.Index(i), sfTag{}); err != nil {
+			return fmt.Errorf("sf: element %d: %w", i, err)
+		}
+	}
+	rv.Set(slice)
+	return nil
+}
+
+func unmarshalBareItem(b BareItem, rv reflect.Value) error {
+	if rv.Type() == timeType {
+		d, ok := b.(Date)
+		if !ok {
+			return fmt.Errorf("sf: cannot unmarshal %T into time.Time", b)
+		}
+		rv.Set(reflect.ValueOf(time.Time(d)))
+		return nil
+	}
+	switch rv.Kind() {
+	case reflect.Bool:
+		v, ok := b.(Bool)
+		if !ok {
+			return fmt.Errorf("sf: cannot unmarshal %T into bool", b)
+		}
+		rv.SetBool(bool(v))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch v := b.(type) {
+		case Integer:
+			rv.SetInt(int64(v))
+		case Date:
+			rv.SetInt(time.Time(v).Unix())
+		default:
+			return fmt.Errorf("sf: cannot unmarshal %T into %s", b, rv.Type())
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v, ok := b.(Integer)
+		if !ok {
+			return fmt.Errorf("sf: cannot unmarshal %T into %s", b, rv.Type())
+		}
+		rv.SetUint(uint64(v))
+	case reflect.Float32, reflect.Float64:
+		v, ok := b.(Decimal)
+		if !ok {
+			return fmt.Errorf("sf: cannot unmarshal %T into %s", b, rv.Type())
+		}
+		rv.SetFloat(float64(v) / 1000)
+	case reflect.String:
+		switch v := b.(type) {
+		case String:
+			rv.SetString(string(v))
+		case Token:
+			rv.SetString(string(v))
+		case DispString:
+			rv.SetString(string(v))
+		default:
+			return fmt.Errorf("sf: cannot unmarshal %T into string", b)
+		}
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			v, ok := b.(ByteSeq)
+			if !ok {
+				return fmt.Errorf("sf: cannot unmarshal %T into []byte", b)
+			}
+			rv.SetBytes([]byte(v))
+			return nil
+		}
+		return fmt.Errorf("sf: cannot unmarshal %T into %s", b, rv.Type())
+	default:
+		return fmt.Errorf("sf: unsupported field type %s", rv.Type())
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package sf
+
+import "fmt"
+
+type examplePriority struct {
+	Urgency     int64 `sf:"u"`
+	Incremental bool  `sf:"i"`
+}
+
+func ExampleMarshal() {
+	s, err := Marshal(examplePriority{Urgency: 3, Incremental: true})
+	if err != nil {
+		fmt.Println(err)
+	} else {
+		fmt.Println(s)
+	}
+
+	// Output:
+	// u=3, i
+}
+
+func ExampleUnmarshal() {
+	var p examplePriority
+	if err := Unmarshal([]string{"u=5"}, &p); err != nil {
+		fmt.Println(err)
+	} else {
+		fmt.Printf("%+v\n", p)
+	}
+
+	// Output:
+	// {Urgency:5 Incremental:false}
+}
+
+type exampleAcceptLang struct {
+	Value  string `sf:"lang,token"`
+	Params Params `sf:"lang,params"`
+}
+
+func ExampleMarshal_withParams() {
+	v := exampleAcceptLang{Value: "en"}
+	v.Params = v.Params.Add("q", Decimal(500))
+	s, err := Marshal(v)
+	if err != nil {
+		fmt.Println(err)
+	} else {
+		fmt.Println(s)
+	}
+
+	// Output:
+	// lang=en;q=0.5
+}
+
+type exampleBadInnerTag struct {
+	Value int64 `sf:"v,inner"`
+}
+
+func ExampleMarshal_nonSliceInnerTag() {
+	// A non-slice field tagged ",inner" is not a valid inner list, but it
+	// must not panic: it marshals as a plain bare item instead.
+	s, err := Marshal(exampleBadInnerTag{Value: 1})
+	if err != nil {
+		fmt.Println(err)
+	} else {
+		fmt.Println(s)
+	}
+
+	// Output:
+	// v=1
+}
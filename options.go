@@ -0,0 +1,189 @@
+package sf
+
+import "fmt"
+
+// ParseMode selects how ParseDictLineWithOptions and ParseListLineWithOptions
+// react to a malformed member.
+type ParseMode int
+
+const (
+	// Strict, the zero value, stops at the first parse error and returns it,
+	// matching ParseDictLine and ParseListLine.
+	Strict ParseMode = iota
+
+	// Lenient skips a malformed member and resumes parsing at the next
+	// top-level member, collecting a ParseError for each one skipped.
+	Lenient
+)
+
+// ParseOptions controls ParseDictLineWithOptions and ParseListLineWithOptions.
+type ParseOptions struct {
+	Mode ParseMode
+}
+
+// ParseError describes one member skipped while parsing in Lenient mode.
+type ParseError struct {
+	// Offset is the byte offset of the skipped member within the header.
+	Offset int
+	// Err is the error that made the member unparsable.
+	Err error
+	// Raw is the skipped member's raw, unparsed text.
+	Raw string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("sf: offset %d: %v", e.Offset, e.Err)
+}
+
+// ParseDictLineWithOptions parses a structured-field dictionary single-line
+// header as ParseDictLine does, except that in Lenient mode a malformed
+// member does not abort the parse: it is skipped, and parsing resumes at the
+// next top-level member. Each skipped member is reported in the returned
+// []ParseError, in order.
+//
+// Like ParseDictLine, it is built on the Scanner; in Strict mode the two
+// functions behave identically.
+func ParseDictLineWithOptions(header string, opts ParseOptions) (Dict, []ParseError, error) {
+	var (
+		dict Dict
+		errs []ParseError
+	)
+	base := 0
+	s := NewDictScanner(header)
+	preStart := skipSpacesStr(header, base+s.pos)
+	ev, err := s.Next()
+	for {
+		memberStart := preStart
+		if err == nil {
+			memberStart = base + ev.Offset
+		}
+		if err == nil && ev.Kind == KindEOF {
+			break
+		}
+		var key string
+		var member Member
+		if err == nil {
+			if ev.Kind != KindKey {
+				err = ErrUnrecognized
+			} else {
+				key = ev.Raw
+				var valStart Event
+				valStart, err = s.Next()
+				if err == nil {
+					member, ev, err = buildMember(s, valStart)
+				}
+			}
+		}
+		if err != nil {
+			if opts.Mode != Lenient {
+				return nil, nil, err
+			}
+			recov := recoverToNextMember([]byte(header), memberStart)
+			errs = append(errs, ParseError{Offset: memberStart, Err: err, Raw: header[memberStart:recov]})
+			if recov >= len(header) {
+				break
+			}
+			base = recov + 1
+			s = NewDictScanner(header[base:])
+			preStart = skipSpacesStr(header, base+s.pos)
+			ev, err = s.Next()
+			continue
+		}
+		dict = dict.Add(key, member)
+		if ev.Kind == KindEOF {
+			break
+		}
+	}
+	return dict, errs, nil
+}
+
+// ParseListLineWithOptions parses a structured-field list single-line header
+// as ParseListLine does, except that in Lenient mode a malformed member does
+// not abort the parse: it is skipped, and parsing resumes at the next
+// top-level member. Each skipped member is reported in the returned
+// []ParseError, in order.
+//
+// Like ParseListLine, it is built on the Scanner; in Strict mode the two
+// functions behave identically.
+func ParseListLineWithOptions(header string, opts ParseOptions) (List, []ParseError, error) {
+	var (
+		list List
+		errs []ParseError
+	)
+	base := 0
+	s := NewListScanner(header)
+	preStart := skipSpacesStr(header, base+s.pos)
+	ev, err := s.Next()
+	for {
+		memberStart := preStart
+		if err == nil {
+			memberStart = base + ev.Offset
+		}
+		if err == nil && ev.Kind == KindEOF {
+			break
+		}
+		var member Member
+		if err == nil {
+			member, ev, err = buildMember(s, ev)
+		}
+		if err != nil {
+			if opts.Mode != Lenient {
+				return nil, nil, err
+			}
+			recov := recoverToNextMember([]byte(header), memberStart)
+			errs = append(errs, ParseError{Offset: memberStart, Err: err, Raw: header[memberStart:recov]})
+			if recov >= len(header) {
+				break
+			}
+			base = recov + 1
+			s = NewListScanner(header[base:])
+			preStart = skipSpacesStr(header, base+s.pos)
+			ev, err = s.Next()
+			continue
+		}
+		list = append(list, member)
+		if ev.Kind == KindEOF {
+			break
+		}
+	}
+	return list, errs, nil
+}
+
+// recoverToNextMember returns the offset of the next top-level comma at or
+// after pos, treating commas inside a quoted string, a byte sequence or an
+// inner list as part of the member rather than a separator. It is the shared
+// recovery step behind Lenient mode in both ParseDictLineWithOptions and
+// ParseListLineWithOptions.
+func recoverToNextMember(input []byte, pos int) int {
+	depth := 0
+	inString := false
+	inByteSeq := false
+	for ; pos < len(input); pos++ {
+		b := input[pos]
+		switch {
+		case inString:
+			if b == '\\' {
+				pos++
+			} else if b == '"' {
+				inString = false
+			}
+		case inByteSeq:
+			if b == ':' {
+				inByteSeq = false
+			}
+		case b == '"':
+			inString = true
+		case b == ':':
+			inByteSeq = true
+		case b == '(':
+			depth++
+		case b == ')':
+			if depth > 0 {
+				depth--
+			}
+		case b == ',' && depth == 0:
+			return pos
+		}
+	}
+	return pos
+}
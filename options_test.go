@@ -0,0 +1,46 @@
+package sf
+
+import "fmt"
+
+func ExampleParseDictLineWithOptions() {
+	dict, errs, err := ParseDictLineWithOptions(`a=1, b=@@, c=3`, ParseOptions{Mode: Lenient})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(dict.Encode())
+	for _, e := range errs {
+		fmt.Println(e.Offset, e.Raw, e.Err)
+	}
+
+	// Output:
+	// a=1, c=3
+	// 5 b=@@ sf: unrecognized char
+}
+
+func ExampleParseListLineWithOptions() {
+	list, errs, err := ParseListLineWithOptions(`1, (1 2, 3`, ParseOptions{Mode: Lenient})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(list.Encode())
+	for _, e := range errs {
+		fmt.Println(e.Offset, e.Raw, e.Err)
+	}
+
+	// Output:
+	// 1
+	// 3 (1 2, 3 sf: unrecognized char
+}
+
+// In Strict mode, ParseDictLineWithOptions and ParseListLineWithOptions match
+// ParseDictLine and ParseListLine exactly, including rejecting a trailing
+// comma.
+func ExampleParseDictLineWithOptions_strictMatchesParseDictLine() {
+	_, _, err := ParseDictLineWithOptions(`a=1,`, ParseOptions{Mode: Strict})
+	fmt.Println(err)
+
+	// Output:
+	// sf: unexpected EOL
+}
@@ -1,9 +1,7 @@
 package sf
 
 import (
-	"encoding/base64"
 	"errors"
-	"strconv"
 	"strings"
 )
 
@@ -16,6 +14,9 @@ var (
 
 	// ErrTooManyDigits reports a too big integer or fractional component.
 	ErrTooManyDigits = errors.New("sf: too many digits")
+
+	// ErrInvalidUTF8 reports a display string with invalid UTF-8 content.
+	ErrInvalidUTF8 = errors.New("sf: invalid UTF-8 in display string")
 )
 
 // Parse parses an structured-field dictionary multi-line header.
@@ -25,28 +26,27 @@ func ParseDict(header []string) (Dict, error) {
 
 // ParseLine parses a structured-field dictionary single-line header.
 func ParseDictLine(header string) (Dict, error) {
-	var (
-		dict  Dict
-		pair  *Pair
-		err   error
-		input = []byte(header)
-		pos   = 0
-	)
-	pos = skipSpaces(input, pos)
-	if pos >= len(input) {
-		return dict, nil
+	var dict Dict
+	s := NewDictScanner(header)
+	ev, err := s.Next()
+	if err != nil {
+		return nil, err
 	}
-	for {
-		pair, pos, err = parsePair(input, pos)
+	for ev.Kind != KindEOF {
+		if ev.Kind != KindKey {
+			return nil, ErrUnrecognized
+		}
+		key := ev.Raw
+		valStart, err := s.Next()
 		if err != nil {
 			return nil, err
 		}
-		dict = dict.Add(pair.Key, pair.Value)
-		pos = skipSpaces(input, pos)
-		if pos >= len(input) || input[pos] != ',' {
-			break
+		member, next, err := buildMember(s, valStart)
+		if err != nil {
+			return nil, err
 		}
-		pos++
+		dict = dict.Add(key, member)
+		ev = next
 	}
 	return dict, nil
 }
@@ -58,41 +58,42 @@ func ParseList(header []string) (List, error) {
 
 // ParseLine parses a structured-field list single-line header.
 func ParseListLine(header string) (List, error) {
-	var (
-		list   List
-		member Member
-		err    error
-		input  = []byte(header)
-		pos    = 0
-	)
-	pos = skipSpaces(input, pos)
-	if pos >= len(input) {
-		return list, nil
+	var list List
+	s := NewListScanner(header)
+	ev, err := s.Next()
+	if err != nil {
+		return nil, err
 	}
-	for {
-		member, pos, err = parseMember(input, pos)
+	for ev.Kind != KindEOF {
+		member, next, err := buildMember(s, ev)
 		if err != nil {
 			return nil, err
 		}
 		list = append(list, member)
-		pos = skipSpaces(input, pos)
-		if pos >= len(input) || input[pos] != ',' {
-			break
-		}
-		pos++
+		ev = next
 	}
 	return list, nil
 }
 
 // ParseLine parses a structured-field item single-line header.
 func ParseItemLine(header string) (*Item, error) {
-	input := []byte(header)
-	it, pos, err := parseItem(input, 0)
+	s := NewListScanner(header)
+	ev, err := s.Next()
+	if err != nil {
+		return nil, err
+	}
+	if ev.Kind == KindEOF {
+		return nil, ErrUnexpectedEOL
+	}
+	member, next, err := buildMember(s, ev)
 	if err != nil {
 		return nil, err
 	}
-	pos = skipSpaces(input, pos)
-	if pos < len(input) {
+	it, ok := member.(*Item)
+	if !ok {
+		return nil, ErrUnrecognized
+	}
+	if next.Kind != KindEOF {
 		return nil, ErrUnrecognized
 	}
 	return it, nil
@@ -108,295 +109,16 @@ func joinMultiLines(header []string) string {
 	return strings.Join(nonEmptyLines, ", ")
 }
 
-func parsePair(input []byte, pos int) (*Pair, int, error) {
-	key, pos, err := parseKey(input, pos)
-	if err != nil {
-		return nil, pos, err
-	}
-	var (
-		value Member
-		p     ParamList
-	)
-	if pos < len(input) && input[pos] == '=' {
-		value, pos, err = parseMember(input, pos+1)
-		if err != nil {
-			return nil, pos, err
-		}
-	} else {
-		p, pos, err = parseParams(input, pos)
-		if err != nil {
-			return nil, pos, err
-		}
-		value = &Item{Bool(true), p}
-	}
-	return &Pair{key, value}, pos, nil
-}
-
-func parseMember(input []byte, pos int) (Member, int, error) {
-	pos = skipSpaces(input, pos)
-	if pos >= len(input) {
-		return nil, pos, ErrUnexpectedEOL
-	}
-	if input[pos] == '(' {
-		return parseInnerList(input, pos)
-	}
-	return parseItem(input, pos)
-}
-
-func parseInnerList(input []byte, pos int) (*InnerList, int, error) {
-	if pos >= len(input) {
-		return nil, pos, ErrUnexpectedEOL
-	}
-	if input[pos] != '(' {
-		return nil, pos, ErrUnrecognized
-	}
-	pos++
-	var (
-		items []Item
-		it    *Item
-		err   error
-	)
-	for {
-		pos = skipSpaces(input, pos)
-		if pos >= len(input) {
-			return nil, pos, ErrUnexpectedEOL
-		}
-		if input[pos] == ')' {
-			pos++
-			break
-		}
-		it, pos, err = parseItem(input, pos)
-		if err != nil {
-			return nil, pos, err
-		}
-		items = append(items, *it)
-	}
-	p, pos, err := parseParams(input, pos)
-	if err != nil {
-		return nil, pos, err
-	}
-	return &InnerList{items, p}, pos, nil
-}
-
-func parseItem(input []byte, pos int) (*Item, int, error) {
-	b, pos, err := parseBareItem(input, pos)
-	if err != nil {
-		return nil, pos, err
-	}
-	p, pos, err := parseParams(input, pos)
-	if err != nil {
-		return nil, pos, err
-	}
-	return &Item{b, p}, pos, nil
-}
-
-func parseParams(input []byte, pos int) (ParamList, int, error) {
-	var (
-		params ParamList
-		key    string
-		value  BareItem
-		err    error
-	)
-	for {
-		pos = skipSpaces(input, pos)
-		if pos >= len(input) || input[pos] != ';' {
-			break
-		}
-		key, pos, err = parseKey(input, pos+1)
-		if err != nil {
-			return nil, pos, err
-		}
-		value = Bool(true)
-		if pos < len(input) && input[pos] == '=' {
-			value, pos, err = parseBareItem(input, pos+1)
-			if err != nil {
-				return nil, pos, err
-			}
-		}
-		params = params.Add(key, value)
-	}
-	return params, pos, nil
-}
-
-func parseKey(input []byte, pos int) (string, int, error) {
-	pos = skipSpaces(input, pos)
-	if pos >= len(input) {
-		return "", pos, ErrUnexpectedEOL
-	}
-	if input[pos] != '*' && !isLower(input[pos]) {
-		return "", pos, ErrUnrecognized
-	}
-	var sb strings.Builder
-	for pos < len(input) && isKeyChar(input[pos]) {
-		sb.WriteByte(input[pos])
-		pos++
-	}
-	return sb.String(), pos, nil
-}
-
-var bareItemParsers = []struct {
-	Cond  func(byte) bool
-	Parse func([]byte, int) (BareItem, int, error)
-}{
-	{func(b byte) bool { return b == '-' || isDigit(b) }, parseNumber},
-	{func(b byte) bool { return b == '"' }, parseString},
-	{func(b byte) bool { return b == '*' || isAlpha(b) }, parseToken},
-	{func(b byte) bool { return b == ':' }, parseByteSeq},
-	{func(b byte) bool { return b == '?' }, parseBool},
-}
-
-func parseBareItem(input []byte, pos int) (BareItem, int, error) {
-	pos = skipSpaces(input, pos)
-	if pos >= len(input) {
-		return nil, pos, ErrUnexpectedEOL
-	}
-	for _, p := range bareItemParsers {
-		if p.Cond(input[pos]) {
-			return p.Parse(input, pos)
-		}
-	}
-	return nil, pos, ErrUnrecognized
-}
-
-func parseNumber(input []byte, pos int) (BareItem, int, error) {
-	if input[pos] != '-' && !isDigit(input[pos]) {
-		return nil, pos, ErrUnrecognized
-	}
-	sign := int64(1)
-	if input[pos] == '-' {
-		sign = -1
-		pos++
-	}
-	if pos == len(input) {
-		return nil, pos, ErrUnexpectedEOL
-	}
-	if !isDigit(input[pos]) {
-		return nil, pos, ErrUnrecognized
-	}
-	var sb strings.Builder
-	decimalPlaces := -1
-	for pos < len(input) {
-		if isDigit(input[pos]) {
-			if sb.Len() == 15 {
-				return nil, pos, ErrTooManyDigits
-			}
-			sb.WriteByte(input[pos])
-			if decimalPlaces >= 0 {
-				if decimalPlaces == 3 {
-					return nil, pos, ErrTooManyDigits
-				}
-				decimalPlaces++
-			}
-		} else if input[pos] == '.' {
-			if decimalPlaces != -1 {
-				break
-			}
-			decimalPlaces = 0
-		} else {
-			break
-		}
-		pos++
-	}
-	n, _ := strconv.ParseInt(sb.String(), 10, 64)
-	switch decimalPlaces {
-	case -1:
-		return Integer(sign * n), pos, nil
-	case 1:
-		return Decimal(sign * n * 100), pos, nil
-	case 2:
-		return Decimal(sign * n * 10), pos, nil
-	case 3:
-		return Decimal(sign * n), pos, nil
-	}
-	return nil, pos, ErrUnrecognized
-}
-
-func parseString(input []byte, pos int) (BareItem, int, error) {
-	if input[pos] != '"' {
-		return nil, pos, ErrUnrecognized
-	}
-	var sb strings.Builder
-	sb.WriteByte(input[pos])
-	pos++
-	for pos < len(input) && input[pos] != '"' {
-		if input[pos] == '\\' {
-			sb.WriteByte(input[pos])
-			pos++
-			if pos == len(input) {
-				return nil, pos, ErrUnexpectedEOL
-			}
-			if input[pos] != '"' && input[pos] != '\\' {
-				return nil, pos, ErrUnrecognized
-			}
-		}
-		if !isPrint(input[pos]) {
-			return nil, pos, ErrUnrecognized
-		}
-		sb.WriteByte(input[pos])
-		pos++
-	}
-	if pos == len(input) {
-		return nil, pos, ErrUnexpectedEOL
-	}
-	sb.WriteByte(input[pos])
-	s, _ := strconv.Unquote(sb.String())
-	return String(s), pos + 1, nil
-}
-
-func parseToken(input []byte, pos int) (BareItem, int, error) {
-	if input[pos] != '*' && !isAlpha(input[pos]) {
-		return nil, pos, ErrUnrecognized
-	}
-	var sb strings.Builder
-	for pos < len(input) && isTokenChar(input[pos]) {
-		sb.WriteByte(input[pos])
-		pos++
-	}
-	return Token(sb.String()), pos, nil
-}
-
-func parseByteSeq(input []byte, pos int) (BareItem, int, error) {
-	if input[pos] != ':' {
-		return nil, pos, ErrUnrecognized
-	}
-	var sb strings.Builder
-	pos++
-	for pos < len(input) && input[pos] != ':' {
-		if !isBase64Char(input[pos]) {
-			return nil, pos, ErrUnrecognized
-		}
-		sb.WriteByte(input[pos])
-		pos++
-	}
-	if pos == len(input) {
-		return nil, pos, ErrUnexpectedEOL
-	}
-	b, _ := base64.StdEncoding.DecodeString(sb.String())
-	return ByteSeq(b), pos + 1, nil
-}
-
-func parseBool(input []byte, pos int) (BareItem, int, error) {
-	if input[pos] != '?' {
-		return nil, pos, ErrUnrecognized
-	}
-	pos++
-	if pos == len(input) {
-		return nil, pos, ErrUnexpectedEOL
-	}
-	switch input[pos] {
-	case '0':
-		return Bool(false), pos + 1, nil
-	case '1':
-		return Bool(true), pos + 1, nil
-	}
-	return nil, pos, ErrUnrecognized
-}
-
-func skipSpaces(input []byte, pos int) int {
-	for pos < len(input) && input[pos] == ' ' {
-		pos++
+func hexVal(b byte) (byte, bool) {
+	switch {
+	case '0' <= b && b <= '9':
+		return b - '0', true
+	case 'a' <= b && b <= 'f':
+		return b - 'a' + 10, true
+	case 'A' <= b && b <= 'F':
+		return b - 'A' + 10, true
 	}
-	return pos
+	return 0, false
 }
 
 func isPrint(b byte) bool {
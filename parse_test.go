@@ -37,6 +37,14 @@ func ExampleParseDictLine() {
 	// a=(1 2), b=3, c=4;aa=bb, d=(5 6);valid
 }
 
+func ExampleParseDictLine_trailingComma() {
+	_, err := ParseDictLine(`a=1,`)
+	fmt.Println(err)
+
+	// Output:
+	// sf: unexpected EOL
+}
+
 func ExampleParseList() {
 	d, err := ParseList([]string{`sugar, tea`, `rum`})
 	if err != nil {
@@ -66,6 +74,14 @@ func ExampleParseListLine() {
 	// sugar, tea, rum
 }
 
+func ExampleParseListLine_trailingComma() {
+	_, err := ParseListLine(`1,`)
+	fmt.Println(err)
+
+	// Output:
+	// sf: unexpected EOL
+}
+
 func ExampleParseItemLine() {
 	tests := []string{
 		`5; foo=bar`,
@@ -88,3 +104,43 @@ func ExampleParseItemLine() {
 	// "hello world"
 	// foo123/456
 }
+
+func ExampleParseItemLine_date() {
+	tests := []string{
+		`@1659578233`,
+		`@-1`,
+	}
+	for _, t := range tests {
+		d, err := ParseItemLine(t)
+		if err != nil {
+			fmt.Println(err)
+		} else {
+			fmt.Println(d.Encode())
+		}
+	}
+
+	// Output:
+	// @1659578233
+	// @-1
+}
+
+func ExampleParseItemLine_dispString() {
+	tests := []string{
+		`%"f%c3%bc%c3%9f"`,
+		`%"plain ascii"`,
+		DispString(`a\b`).Encode(),
+	}
+	for _, t := range tests {
+		d, err := ParseItemLine(t)
+		if err != nil {
+			fmt.Println(err)
+		} else {
+			fmt.Println(d.Encode())
+		}
+	}
+
+	// Output:
+	// %"f%c3%bc%c3%9f"
+	// %"plain ascii"
+	// %"a\b"
+}
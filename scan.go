@@ -0,0 +1,658 @@
+package sf
+
+import (
+	"encoding/base64"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// Kind identifies the kind of token produced by a Scanner.
+type Kind uint8
+
+const (
+	// KindEOF marks the end of the scanned header.
+	KindEOF Kind = iota
+	// KindKey is a dictionary member's key.
+	KindKey
+	// KindParamKey is a parameter's key, following a value or an
+	// KindInnerListEnd.
+	KindParamKey
+	// KindInnerListStart marks the opening "(" of an inner list.
+	KindInnerListStart
+	// KindInnerListEnd marks the closing ")" of an inner list.
+	KindInnerListEnd
+	// KindInteger is an Integer bare item value.
+	KindInteger
+	// KindDecimal is a Decimal bare item value.
+	KindDecimal
+	// KindString is a String bare item value.
+	KindString
+	// KindToken is a Token bare item value.
+	KindToken
+	// KindByteSeq is a ByteSeq bare item value.
+	KindByteSeq
+	// KindBool is a Bool bare item value.
+	KindBool
+	// KindDate is a Date bare item value.
+	KindDate
+	// KindDispString is a DispString bare item value.
+	KindDispString
+)
+
+var kindNames = [...]string{
+	KindEOF:            "EOF",
+	KindKey:            "Key",
+	KindParamKey:       "ParamKey",
+	KindInnerListStart: "InnerListStart",
+	KindInnerListEnd:   "InnerListEnd",
+	KindInteger:        "Integer",
+	KindDecimal:        "Decimal",
+	KindString:         "String",
+	KindToken:          "Token",
+	KindByteSeq:        "ByteSeq",
+	KindBool:           "Bool",
+	KindDate:           "Date",
+	KindDispString:     "DispString",
+}
+
+// String returns the name of the kind, for use in logging and tests.
+func (k Kind) String() string {
+	if int(k) < len(kindNames) {
+		return kindNames[k]
+	}
+	return "Unknown"
+}
+
+// Event is one element of a Scanner's output. For bare item kinds, Raw holds
+// the still-encoded source text so that a caller who only needs to skip or
+// compare a value never pays to decode it: a String keeps its quotes and a
+// Date its "@", while a ByteSeq's colons and a DispString's `%"` and closing
+// quote are left out. A Bool implied by a key without a value has Raw "?1".
+// Use the Decoded* methods to get the Go value. KindInteger, KindBool and
+// KindDate are small enough that Int already holds the decoded value.
+type Event struct {
+	Kind   Kind
+	Offset int
+	Raw    string
+	Int    int64
+}
+
+// DecodedBool returns the decoded value of a KindBool token.
+func (t Event) DecodedBool() bool {
+	return t.Int != 0
+}
+
+// DecodedDecimal returns the decoded value of a KindDecimal token.
+func (t Event) DecodedDecimal() Decimal {
+	return Decimal(t.Int)
+}
+
+// DecodedString unescapes a KindString token's Raw text.
+func (t Event) DecodedString() (string, error) {
+	s, err := strconv.Unquote(t.Raw)
+	if err != nil {
+		return "", ErrUnrecognized
+	}
+	return s, nil
+}
+
+// DecodedBytes base64-decodes a KindByteSeq token's Raw text.
+func (t Event) DecodedBytes() ([]byte, error) {
+	b, err := base64.StdEncoding.DecodeString(t.Raw)
+	if err != nil {
+		return nil, ErrUnrecognized
+	}
+	return b, nil
+}
+
+// DecodedDispString percent-decodes a KindDispString token's Raw text and
+// validates the result as UTF-8.
+func (t Event) DecodedDispString() (string, error) {
+	b := make([]byte, 0, len(t.Raw))
+	for i := 0; i < len(t.Raw); i++ {
+		if t.Raw[i] != '%' {
+			b = append(b, t.Raw[i])
+			continue
+		}
+		if i+2 >= len(t.Raw) {
+			return "", ErrUnexpectedEOL
+		}
+		hi, ok1 := hexVal(t.Raw[i+1])
+		lo, ok2 := hexVal(t.Raw[i+2])
+		if !ok1 || !ok2 {
+			return "", ErrUnrecognized
+		}
+		b = append(b, hi<<4|lo)
+		i += 2
+	}
+	if !utf8.Valid(b) {
+		return "", ErrInvalidUTF8
+	}
+	return string(b), nil
+}
+
+// scanState drives the Scanner's state machine. Structured fields nest at
+// most one inner list deep, so a fixed set of states suffices; no general
+// stack is needed.
+type scanState uint8
+
+const (
+	stTopMember scanState = iota
+	stAfterKey
+	stTopParamKey
+	stTopParamValue
+	stInnerItem
+	stInnerItemParamKey
+	stInnerItemParamValue
+)
+
+// Scanner walks a structured-field header in place and emits an Event per
+// call to Next, without allocating for keys, tokens, integers, decimals or
+// booleans. It is the allocation-free core that ParseDictLine and
+// ParseListLine are built on; most callers should use those instead and
+// reach for a Scanner only in allocation-sensitive code such as proxies or
+// signature verifiers that inspect every request.
+type Scanner struct {
+	input string
+	pos   int
+	dict  bool
+	state scanState
+	done  bool
+
+	// afterComma is true when the scanner has just consumed a top-level
+	// member separator and has not yet produced any token for the next
+	// member. It distinguishes a trailing comma (an error, RFC 8941
+	// Section 4.2 step 2.9) from genuine end of input.
+	afterComma bool
+}
+
+// NewDictScanner returns a Scanner over a dictionary header.
+func NewDictScanner(header string) *Scanner {
+	return &Scanner{input: header, dict: true}
+}
+
+// NewListScanner returns a Scanner over a list header.
+func NewListScanner(header string) *Scanner {
+	return &Scanner{input: header}
+}
+
+// Next returns the next token in the header, or a KindEOF token once
+// exhausted. It returns an error if the header is malformed at the current
+// position.
+func (s *Scanner) Next() (Event, error) {
+	for {
+		if s.done {
+			return Event{Kind: KindEOF, Offset: s.pos}, nil
+		}
+		switch s.state {
+		case stTopMember:
+			s.pos = skipSpacesStr(s.input, s.pos)
+			if s.pos >= len(s.input) {
+				if s.afterComma {
+					return Event{}, ErrUnexpectedEOL
+				}
+				s.done = true
+				continue
+			}
+			s.afterComma = false
+			if s.dict {
+				off := s.pos
+				key, pos, err := parseKeyStr(s.input, s.pos)
+				if err != nil {
+					return Event{}, err
+				}
+				s.pos = pos
+				s.state = stAfterKey
+				return Event{Kind: KindKey, Offset: off, Raw: key}, nil
+			}
+			return s.scanValue(stTopParamKey)
+		case stAfterKey:
+			if s.pos < len(s.input) && s.input[s.pos] == '=' {
+				s.pos++
+				return s.scanValue(stTopParamKey)
+			}
+			s.state = stTopParamKey
+			return Event{Kind: KindBool, Offset: s.pos, Raw: "?1", Int: 1}, nil
+		case stTopParamKey:
+			tok, found, err := s.scanParamKey(stTopParamValue)
+			if err != nil {
+				return Event{}, err
+			}
+			if found {
+				return tok, nil
+			}
+			s.pos = skipSpacesStr(s.input, s.pos)
+			if s.pos >= len(s.input) {
+				s.done = true
+				continue
+			}
+			if s.input[s.pos] != ',' {
+				return Event{}, ErrUnrecognized
+			}
+			s.pos++
+			s.afterComma = true
+			s.state = stTopMember
+			continue
+		case stTopParamValue:
+			return s.scanParamValue(stTopParamKey)
+		case stInnerItem:
+			s.pos = skipSpacesStr(s.input, s.pos)
+			if s.pos >= len(s.input) {
+				return Event{}, ErrUnexpectedEOL
+			}
+			if s.input[s.pos] == ')' {
+				off := s.pos
+				s.pos++
+				s.state = stTopParamKey
+				return Event{Kind: KindInnerListEnd, Offset: off}, nil
+			}
+			return s.scanValue(stInnerItemParamKey)
+		case stInnerItemParamKey:
+			tok, found, err := s.scanParamKey(stInnerItemParamValue)
+			if err != nil {
+				return Event{}, err
+			}
+			if found {
+				return tok, nil
+			}
+			s.state = stInnerItem
+			continue
+		case stInnerItemParamValue:
+			return s.scanParamValue(stInnerItemParamKey)
+		}
+	}
+}
+
+// scanValue scans a single bare item, or an inner list's opening "(", at
+// the current position and sets the state to run after the value.
+func (s *Scanner) scanValue(next scanState) (Event, error) {
+	if s.pos >= len(s.input) {
+		return Event{}, ErrUnexpectedEOL
+	}
+	if s.input[s.pos] == '(' {
+		off := s.pos
+		s.pos++
+		s.state = stInnerItem
+		return Event{Kind: KindInnerListStart, Offset: off}, nil
+	}
+	tok, pos, err := scanBareItem(s.input, s.pos)
+	if err != nil {
+		return Event{}, err
+	}
+	s.pos = pos
+	s.state = next
+	return tok, nil
+}
+
+// scanParamKey scans a leading ";key" at the current position. found is
+// false once no ";" remains, leaving pos unchanged. If found, the state
+// moves to valueState so the next Next call scans the optional "=value".
+func (s *Scanner) scanParamKey(valueState scanState) (Event, bool, error) {
+	pos := skipSpacesStr(s.input, s.pos)
+	if pos >= len(s.input) || s.input[pos] != ';' {
+		return Event{}, false, nil
+	}
+	off := pos
+	key, pos, err := parseKeyStr(s.input, pos+1)
+	if err != nil {
+		return Event{}, false, err
+	}
+	s.pos = pos
+	s.state = valueState
+	return Event{Kind: KindParamKey, Offset: off, Raw: key}, true, nil
+}
+
+// scanParamValue scans the optional "=value" following a parameter key,
+// returning a synthetic Bool(true) token when it is absent, and moves the
+// state back to keyState to look for further parameters.
+func (s *Scanner) scanParamValue(keyState scanState) (Event, error) {
+	if s.pos < len(s.input) && s.input[s.pos] == '=' {
+		s.pos++
+		tok, pos, err := scanBareItem(s.input, s.pos)
+		if err != nil {
+			return Event{}, err
+		}
+		s.pos = pos
+		s.state = keyState
+		return tok, nil
+	}
+	s.state = keyState
+	return Event{Kind: KindBool, Offset: s.pos, Raw: "?1", Int: 1}, nil
+}
+
+func scanBareItem(input string, pos int) (Event, int, error) {
+	pos = skipSpacesStr(input, pos)
+	if pos >= len(input) {
+		return Event{}, pos, ErrUnexpectedEOL
+	}
+	off := pos
+	switch b := input[pos]; {
+	case b == '-' || isDigit(b):
+		return scanNumber(input, pos)
+	case b == '"':
+		return scanString(input, pos)
+	case b == '*' || isAlpha(b):
+		return scanToken(input, pos)
+	case b == ':':
+		return scanByteSeq(input, pos)
+	case b == '?':
+		return scanBool(input, pos)
+	case b == '@':
+		return scanDate(input, pos)
+	case b == '%':
+		return scanDispString(input, pos)
+	default:
+		return Event{}, off, ErrUnrecognized
+	}
+}
+
+func scanNumber(input string, pos int) (Event, int, error) {
+	off := pos
+	if input[pos] != '-' && !isDigit(input[pos]) {
+		return Event{}, pos, ErrUnrecognized
+	}
+	sign := int64(1)
+	if input[pos] == '-' {
+		sign = -1
+		pos++
+	}
+	if pos == len(input) {
+		return Event{}, pos, ErrUnexpectedEOL
+	}
+	if !isDigit(input[pos]) {
+		return Event{}, pos, ErrUnrecognized
+	}
+	start := pos
+	digits := 0
+	decimalPlaces := -1
+	for pos < len(input) {
+		if isDigit(input[pos]) {
+			if digits == 15 {
+				return Event{}, pos, ErrTooManyDigits
+			}
+			digits++
+			if decimalPlaces >= 0 {
+				if decimalPlaces == 3 {
+					return Event{}, pos, ErrTooManyDigits
+				}
+				decimalPlaces++
+			}
+		} else if input[pos] == '.' {
+			if decimalPlaces != -1 {
+				break
+			}
+			decimalPlaces = 0
+		} else {
+			break
+		}
+		pos++
+	}
+	n, _ := strconv.ParseInt(removeDot(input[start:pos]), 10, 64)
+	raw := input[off:pos]
+	switch decimalPlaces {
+	case -1:
+		return Event{Kind: KindInteger, Offset: off, Raw: raw, Int: sign * n}, pos, nil
+	case 1:
+		return Event{Kind: KindDecimal, Offset: off, Raw: raw, Int: sign * n * 100}, pos, nil
+	case 2:
+		return Event{Kind: KindDecimal, Offset: off, Raw: raw, Int: sign * n * 10}, pos, nil
+	case 3:
+		return Event{Kind: KindDecimal, Offset: off, Raw: raw, Int: sign * n}, pos, nil
+	}
+	return Event{}, off, ErrUnrecognized
+}
+
+func removeDot(s string) string {
+	if i := indexByte(s, '.'); i >= 0 {
+		return s[:i] + s[i+1:]
+	}
+	return s
+}
+
+func indexByte(s string, c byte) int {
+	for i := 0; i < len(s); i++ {
+		if s[i] == c {
+			return i
+		}
+	}
+	return -1
+}
+
+func scanString(input string, pos int) (Event, int, error) {
+	off := pos
+	pos++
+	for pos < len(input) && input[pos] != '"' {
+		if input[pos] == '\\' {
+			pos++
+			if pos == len(input) {
+				return Event{}, pos, ErrUnexpectedEOL
+			}
+			if input[pos] != '"' && input[pos] != '\\' {
+				return Event{}, pos, ErrUnrecognized
+			}
+		}
+		if !isPrint(input[pos]) {
+			return Event{}, pos, ErrUnrecognized
+		}
+		pos++
+	}
+	if pos == len(input) {
+		return Event{}, pos, ErrUnexpectedEOL
+	}
+	pos++
+	return Event{Kind: KindString, Offset: off, Raw: input[off:pos]}, pos, nil
+}
+
+func scanToken(input string, pos int) (Event, int, error) {
+	off := pos
+	for pos < len(input) && isTokenChar(input[pos]) {
+		pos++
+	}
+	return Event{Kind: KindToken, Offset: off, Raw: input[off:pos]}, pos, nil
+}
+
+func scanByteSeq(input string, pos int) (Event, int, error) {
+	off := pos
+	pos++
+	for pos < len(input) && input[pos] != ':' {
+		if !isBase64Char(input[pos]) {
+			return Event{}, pos, ErrUnrecognized
+		}
+		pos++
+	}
+	if pos == len(input) {
+		return Event{}, pos, ErrUnexpectedEOL
+	}
+	return Event{Kind: KindByteSeq, Offset: off, Raw: input[off+1 : pos]}, pos + 1, nil
+}
+
+func scanBool(input string, pos int) (Event, int, error) {
+	off := pos
+	pos++
+	if pos == len(input) {
+		return Event{}, pos, ErrUnexpectedEOL
+	}
+	switch input[pos] {
+	case '0':
+		return Event{Kind: KindBool, Offset: off, Raw: input[off : pos+1]}, pos + 1, nil
+	case '1':
+		return Event{Kind: KindBool, Offset: off, Raw: input[off : pos+1], Int: 1}, pos + 1, nil
+	}
+	return Event{}, pos, ErrUnrecognized
+}
+
+func scanDate(input string, pos int) (Event, int, error) {
+	off := pos
+	if pos+1 >= len(input) {
+		return Event{}, pos + 1, ErrUnexpectedEOL
+	}
+	n, pos, err := scanNumber(input, pos+1)
+	if err != nil {
+		return Event{}, pos, err
+	}
+	if n.Kind != KindInteger {
+		return Event{}, off, ErrUnrecognized
+	}
+	return Event{Kind: KindDate, Offset: off, Raw: input[off:pos], Int: n.Int}, pos, nil
+}
+
+func scanDispString(input string, pos int) (Event, int, error) {
+	off := pos
+	pos++
+	if pos >= len(input) || input[pos] != '"' {
+		return Event{}, pos, ErrUnrecognized
+	}
+	pos++
+	start := pos
+	for pos < len(input) && input[pos] != '"' {
+		if input[pos] == '%' {
+			if pos+2 >= len(input) {
+				return Event{}, pos, ErrUnexpectedEOL
+			}
+			if _, ok := hexVal(input[pos+1]); !ok {
+				return Event{}, pos, ErrUnrecognized
+			}
+			if _, ok := hexVal(input[pos+2]); !ok {
+				return Event{}, pos, ErrUnrecognized
+			}
+			pos += 3
+			continue
+		}
+		if !isPrint(input[pos]) {
+			return Event{}, pos, ErrUnrecognized
+		}
+		pos++
+	}
+	if pos == len(input) {
+		return Event{}, pos, ErrUnexpectedEOL
+	}
+	return Event{Kind: KindDispString, Offset: off, Raw: input[start:pos]}, pos + 1, nil
+}
+
+func skipSpacesStr(input string, pos int) int {
+	for pos < len(input) && input[pos] == ' ' {
+		pos++
+	}
+	return pos
+}
+
+func parseKeyStr(input string, pos int) (string, int, error) {
+	pos = skipSpacesStr(input, pos)
+	if pos >= len(input) {
+		return "", pos, ErrUnexpectedEOL
+	}
+	if input[pos] != '*' && !isLower(input[pos]) {
+		return "", pos, ErrUnrecognized
+	}
+	start := pos
+	for pos < len(input) && isKeyChar(input[pos]) {
+		pos++
+	}
+	return input[start:pos], pos, nil
+}
+
+// bareItemFromEvent decodes a bare-item-kind Event into the BareItem it
+// represents. ParseDictLine, ParseListLine and ParseItemLine are built on
+// this and buildMember, so the tree-based parsers share the Scanner's
+// low-level scanning instead of walking the input a second time.
+func bareItemFromEvent(ev Event) (BareItem, error) {
+	switch ev.Kind {
+	case KindInteger:
+		return Integer(ev.Int), nil
+	case KindDecimal:
+		return ev.DecodedDecimal(), nil
+	case KindString:
+		s, err := ev.DecodedString()
+		if err != nil {
+			return nil, err
+		}
+		return String(s), nil
+	case KindToken:
+		return Token(ev.Raw), nil
+	case KindByteSeq:
+		b, err := ev.DecodedBytes()
+		if err != nil {
+			return nil, err
+		}
+		return ByteSeq(b), nil
+	case KindBool:
+		return Bool(ev.DecodedBool()), nil
+	case KindDate:
+		return Date(time.Unix(ev.Int, 0).UTC()), nil
+	case KindDispString:
+		s, err := ev.DecodedDispString()
+		if err != nil {
+			return nil, err
+		}
+		return DispString(s), nil
+	}
+	return nil, ErrUnrecognized
+}
+
+// buildParams reads zero or more trailing ";key" or ";key=value" parameters
+// from s, returning the first Event that is not a parameter key (the start
+// of the next member, or KindEOF) so the caller can continue from it
+// without needing to push it back onto the Scanner.
+func buildParams(s *Scanner) (ParamList, Event, error) {
+	var params ParamList
+	for {
+		ev, err := s.Next()
+		if err != nil {
+			return nil, Event{}, err
+		}
+		if ev.Kind != KindParamKey {
+			return params, ev, nil
+		}
+		key := ev.Raw
+		val, err := s.Next()
+		if err != nil {
+			return nil, Event{}, err
+		}
+		b, err := bareItemFromEvent(val)
+		if err != nil {
+			return nil, Event{}, err
+		}
+		params = params.Add(key, b)
+	}
+}
+
+// buildMember builds one Item or InnerList from s, given start, the Event
+// already read for its first token (a bare item, or an InnerListStart). It
+// returns the first Event after the member's own trailing parameters, for
+// the caller to continue from.
+func buildMember(s *Scanner, start Event) (Member, Event, error) {
+	if start.Kind != KindInnerListStart {
+		b, err := bareItemFromEvent(start)
+		if err != nil {
+			return nil, Event{}, err
+		}
+		params, next, err := buildParams(s)
+		if err != nil {
+			return nil, Event{}, err
+		}
+		return &Item{Bare: b, Params: params}, next, nil
+	}
+	var items []Item
+	ev, err := s.Next()
+	if err != nil {
+		return nil, Event{}, err
+	}
+	for ev.Kind != KindInnerListEnd {
+		b, err := bareItemFromEvent(ev)
+		if err != nil {
+			return nil, Event{}, err
+		}
+		itemParams, next, err := buildParams(s)
+		if err != nil {
+			return nil, Event{}, err
+		}
+		items = append(items, Item{Bare: b, Params: itemParams})
+		ev = next
+	}
+	params, next, err := buildParams(s)
+	if err != nil {
+		return nil, Event{}, err
+	}
+	return &InnerList{Items: items, Params: params}, next, nil
+}
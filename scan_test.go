@@ -0,0 +1,35 @@
+package sf
+
+import "fmt"
+
+func ExampleScanner() {
+	s := NewDictScanner(`a=1, b=(x y);q=0.5, c`)
+	for {
+		ev, err := s.Next()
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if ev.Kind == KindEOF {
+			return
+		}
+		if ev.Raw == "" {
+			fmt.Println(ev.Kind)
+		} else {
+			fmt.Println(ev.Kind, ev.Raw)
+		}
+	}
+
+	// Output:
+	// Key a
+	// Integer 1
+	// Key b
+	// InnerListStart
+	// Token x
+	// Token y
+	// InnerListEnd
+	// ParamKey q
+	// Decimal 0.5
+	// Key c
+	// Bool ?1
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Encoder defines a structured-field type with support for encoding.
@@ -269,12 +270,49 @@ func (b Bool) Encode() string {
 	return "?0"
 }
 
+// Date is a date item, added by RFC9651, holding a Unix timestamp with
+// second precision.
+//
+// Incompatibility note: RFC9651, Section 3.3.7, describes dates as integers
+// up to 15 digits, same as Integer. Here, we are storing the timestamp as a
+// time.Time for convenience; values outside the 15-digit range will not
+// round-trip correctly.
+type Date time.Time
+
+// Encode serializes the date item.
+func (d Date) Encode() string {
+	return "@" + strconv.FormatInt(time.Time(d).Unix(), 10)
+}
+
+// DispString is a display string item, added by RFC9651, holding a sequence
+// of Unicode codepoints encoded as UTF-8.
+type DispString string
+
+// Encode serializes the display string item, percent-encoding '%', '"' and
+// any byte outside printable ASCII.
+func (s DispString) Encode() string {
+	var sb strings.Builder
+	sb.WriteString(`%"`)
+	for i := 0; i < len(s); i++ {
+		b := s[i]
+		if b == '%' || b == '"' || !isPrint(b) {
+			fmt.Fprintf(&sb, "%%%02x", b)
+			continue
+		}
+		sb.WriteByte(b)
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
 func (i InnerList) isMember() {}
 func (i Item) isMember()      {}
 
-func (Integer) isBareItem() {}
-func (Decimal) isBareItem() {}
-func (String) isBareItem()  {}
-func (Token) isBareItem()   {}
-func (ByteSeq) isBareItem() {}
-func (Bool) isBareItem()    {}
+func (Integer) isBareItem()    {}
+func (Decimal) isBareItem()    {}
+func (String) isBareItem()     {}
+func (Token) isBareItem()      {}
+func (ByteSeq) isBareItem()    {}
+func (Bool) isBareItem()       {}
+func (Date) isBareItem()       {}
+func (DispString) isBareItem() {}
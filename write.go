@@ -0,0 +1,191 @@
+package sf
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// wrFrame tracks one level of nesting while writing: a top-level dict, a
+// top-level list, or an inner list.
+type wrFrame struct {
+	kind       wrKind
+	first      bool
+	pendingKey bool
+}
+
+type wrKind uint8
+
+const (
+	wrDict wrKind = iota
+	wrList
+	wrInner
+)
+
+// Writer writes a structured-field header directly to an io.Writer, a
+// member at a time, without materializing a Dict, List or Item tree. It is
+// the writing counterpart of Scanner. Dict.Encode and List.Encode do not use
+// it; they build their output from the tree.
+//
+// Methods do not return an error individually; call Err after writing to
+// check whether anything failed. Once a write fails, subsequent method
+// calls are no-ops.
+type Writer struct {
+	w     io.Writer
+	err   error
+	stack []wrFrame
+}
+
+// NewDictWriter returns a Writer that writes a dictionary header.
+func NewDictWriter(w io.Writer) *Writer {
+	return &Writer{w: w, stack: []wrFrame{{kind: wrDict, first: true}}}
+}
+
+// NewListWriter returns a Writer that writes a list header.
+func NewListWriter(w io.Writer) *Writer {
+	return &Writer{w: w, stack: []wrFrame{{kind: wrList, first: true}}}
+}
+
+// Err returns the first error encountered while writing, if any.
+func (wr *Writer) Err() error {
+	return wr.err
+}
+
+func (wr *Writer) write(s string) {
+	if wr.err != nil {
+		return
+	}
+	_, wr.err = io.WriteString(wr.w, s)
+}
+
+func (wr *Writer) top() *wrFrame {
+	return &wr.stack[len(wr.stack)-1]
+}
+
+// beginValue writes whatever separator or "=" precedes the next value at
+// the current nesting level.
+func (wr *Writer) beginValue() {
+	f := wr.top()
+	switch f.kind {
+	case wrDict:
+		if !f.pendingKey {
+			wr.err = fmt.Errorf("sf: Writer: value written without a preceding Key")
+			return
+		}
+		wr.write("=")
+		f.pendingKey = false
+	case wrList:
+		if !f.first {
+			wr.write(", ")
+		}
+		f.first = false
+	case wrInner:
+		if !f.first {
+			wr.write(" ")
+		}
+		f.first = false
+	}
+}
+
+// Key writes a dictionary member's key. It is only valid when the current
+// level is a dict (the top level of a Writer from NewDictWriter).
+func (wr *Writer) Key(k string) {
+	if wr.err != nil {
+		return
+	}
+	f := wr.top()
+	if f.kind != wrDict {
+		wr.err = fmt.Errorf("sf: Writer: Key called outside a dict")
+		return
+	}
+	if !f.first {
+		wr.write(", ")
+	}
+	f.first = false
+	wr.write(k)
+	f.pendingKey = true
+}
+
+// Integer writes an Integer value.
+func (wr *Writer) Integer(v int64) {
+	wr.value(Integer(v))
+}
+
+// Decimal writes a Decimal value.
+func (wr *Writer) Decimal(v Decimal) {
+	wr.value(v)
+}
+
+// String writes a String value.
+func (wr *Writer) String(v string) {
+	wr.value(String(v))
+}
+
+// Token writes a Token value.
+func (wr *Writer) Token(v string) {
+	wr.value(Token(v))
+}
+
+// Bytes writes a ByteSeq value.
+func (wr *Writer) Bytes(v []byte) {
+	wr.value(ByteSeq(v))
+}
+
+// Bool writes a Bool value.
+func (wr *Writer) Bool(v bool) {
+	wr.value(Bool(v))
+}
+
+// Date writes a Date value.
+func (wr *Writer) Date(v time.Time) {
+	wr.value(Date(v))
+}
+
+// DispString writes a Display String value.
+func (wr *Writer) DispString(v string) {
+	wr.value(DispString(v))
+}
+
+func (wr *Writer) value(b BareItem) {
+	if wr.err != nil {
+		return
+	}
+	wr.beginValue()
+	wr.write(b.Encode())
+}
+
+// BeginInnerList opens an inner list as the current member's value.
+func (wr *Writer) BeginInnerList() {
+	if wr.err != nil {
+		return
+	}
+	wr.beginValue()
+	wr.write("(")
+	wr.stack = append(wr.stack, wrFrame{kind: wrInner, first: true})
+}
+
+// EndInnerList closes the innermost open inner list.
+func (wr *Writer) EndInnerList() {
+	if wr.err != nil {
+		return
+	}
+	if wr.top().kind != wrInner {
+		wr.err = fmt.Errorf("sf: Writer: EndInnerList without a matching BeginInnerList")
+		return
+	}
+	wr.stack = wr.stack[:len(wr.stack)-1]
+	wr.write(")")
+}
+
+// Param writes a ";key" or ";key=value" parameter on the member, item or
+// inner list most recently written.
+func (wr *Writer) Param(k string, v BareItem) {
+	if wr.err != nil {
+		return
+	}
+	if b, ok := v.(Bool); ok && bool(b) {
+		wr.write(";" + k)
+		return
+	}
+	wr.write(";" + k + "=" + v.Encode())
+}
@@ -0,0 +1,27 @@
+package sf
+
+import (
+	"bytes"
+	"fmt"
+)
+
+func ExampleWriter() {
+	var buf bytes.Buffer
+	wr := NewDictWriter(&buf)
+	wr.Key("a")
+	wr.Integer(1)
+	wr.Key("b")
+	wr.BeginInnerList()
+	wr.Token("x")
+	wr.Token("y")
+	wr.EndInnerList()
+	wr.Param("q", Decimal(500))
+	if err := wr.Err(); err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(buf.String())
+
+	// Output:
+	// a=1, b=(x y);q=0.5
+}